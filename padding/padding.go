@@ -8,19 +8,58 @@ import (
 
 	"github.com/mattn/go-runewidth"
 	"github.com/muesli/reflow/ansi"
+	"github.com/rivo/uniseg"
 )
 
 type PaddingFunc func(w io.Writer)
 
+// Align controls where the fill for a line's padding is placed relative to
+// its content.
+type Align int
+
+const (
+	// AlignLeft pads on the right of the content. This is the default.
+	AlignLeft Align = iota
+	// AlignRight pads on the left of the content.
+	AlignRight
+	// AlignCenter splits the fill evenly on both sides of the content,
+	// biasing the extra cell (if the fill is odd) to the right.
+	AlignCenter
+)
+
 type Writer struct {
 	Padding uint
 	PadFunc PaddingFunc
+	Align   Align
+
+	// Truncate, when set, drops any content past Padding cells instead of
+	// passing it through. Ellipsis, if non-empty, is inserted at the
+	// truncation point and counts against the cell budget.
+	Truncate bool
+	Ellipsis string
+
+	// FastWidth, when set, opts out of grapheme-cluster-aware width
+	// measurement and falls back to the cheaper, but less accurate,
+	// per-rune runewidth.RuneWidth calculation. By default width is
+	// measured one grapheme cluster at a time (via uniseg), so that emoji
+	// sequences, flags and combining marks are accounted for correctly.
+	FastWidth bool
 
 	ansiWriter *ansi.Writer
 	buf        bytes.Buffer
 	cache      bytes.Buffer
+	line       bytes.Buffer
 	lineLen    int
 	ansi       bool
+	truncated  bool
+	pipe       bool
+
+	// unitWidths and unitEnds record, for the current line, the width and
+	// line.Bytes() end offset of each content unit written so far. They let
+	// truncateLine trim previously written units off the tail once Padding
+	// is actually exceeded, instead of reserving Ellipsis's width up front.
+	unitWidths []int
+	unitEnds   []int
 }
 
 func NewWriter(width uint, paddingFunc PaddingFunc) *Writer {
@@ -34,6 +73,12 @@ func NewWriter(width uint, paddingFunc PaddingFunc) *Writer {
 	return w
 }
 
+// NewWriterPipe returns a Writer that forwards each line's content and
+// padding to forward as soon as it's known, i.e. when '\n' is observed, or
+// when Close/Flush completes the final, unterminated line. Only the
+// in-progress line is ever buffered, so it's safe to use in long-running
+// TTY/log pipelines without unbounded memory growth. Bytes() and String()
+// are no-ops in this mode; retrieve output from forward instead.
 func NewWriterPipe(forward io.Writer, width uint, paddingFunc PaddingFunc) *Writer {
 	return &Writer{
 		Padding: width,
@@ -41,6 +86,7 @@ func NewWriterPipe(forward io.Writer, width uint, paddingFunc PaddingFunc) *Writ
 		ansiWriter: &ansi.Writer{
 			Forward: forward,
 		},
+		pipe: true,
 	}
 }
 
@@ -67,59 +113,244 @@ func String(s string, width uint) string {
 
 // Write is used to write content to the padding buffer.
 func (w *Writer) Write(b []byte) (int, error) {
-	for _, c := range string(b) {
-		if c == '\x1B' {
-			// ANSI escape sequence
-			w.ansi = true
-		} else if w.ansi {
-			if (c >= 0x41 && c <= 0x5a) || (c >= 0x61 && c <= 0x7a) {
-				// ANSI sequence terminated
-				w.ansi = false
+	if w.FastWidth {
+		for _, c := range string(b) {
+			if err := w.writeChar(c); err != nil {
+				return 0, err
 			}
-		} else {
-			w.lineLen += runewidth.RuneWidth(c)
+		}
+
+		return len(b), nil
+	}
+
+	return w.writeGraphemes(b)
+}
+
+// writeGraphemes feeds b through the state machine one grapheme cluster at
+// a time, so multi-rune clusters (emoji with ZWJ, flags, skin-tone
+// modifiers, combining marks, ...) are measured and written as a single
+// unit. It steps directly over b via uniseg.FirstGraphemeCluster, which
+// hands back each cluster's width already computed, so there's no need to
+// convert b to a string up front or re-derive a cluster's width afterwards.
+func (w *Writer) writeGraphemes(b []byte) (int, error) {
+	n := len(b)
+	state := -1
+
+	for len(b) > 0 {
+		var cluster []byte
+		var cw int
+		cluster, b, cw, state = uniseg.FirstGraphemeCluster(b, state)
 
-			if c == '\n' {
-				// end of current line
-				err := w.pad()
-				if err != nil {
+		if string(cluster) == "\r\n" {
+			// CR LF is a single grapheme cluster; feed it rune by rune so
+			// that the '\n' still ends the line.
+			for _, r := range "\r\n" {
+				if err := w.writeChar(r); err != nil {
 					return 0, err
 				}
-				w.ansiWriter.ResetAnsi()
-				w.lineLen = 0
 			}
+			continue
+		}
+
+		if r, size := utf8.DecodeRune(cluster); size == len(cluster) {
+			// Apart from CR LF (handled above), ANSI escape sequences,
+			// newlines and plain runes never form a multi-rune cluster, so
+			// they take the regular per-rune path.
+			if err := w.writeChar(r); err != nil {
+				return 0, err
+			}
+			continue
 		}
 
-		_, err := w.writeRune(c)
-		if err != nil {
+		if err := w.writeContent(cluster, cw); err != nil {
 			return 0, err
 		}
 	}
 
-	return len(b), nil
+	return n, nil
 }
 
-func (w *Writer) pad() error {
-	if w.Padding > 0 && uint(w.lineLen) < w.Padding {
-		if w.PadFunc != nil {
-			for i := 0; i < int(w.Padding)-w.lineLen; i++ {
-				w.PadFunc(w.ansiWriter)
-			}
-		} else {
-			_, err := w.ansiWriter.Write(bytes.Repeat([]byte(" "), int(w.Padding)-w.lineLen))
-			if err != nil {
-				return err
-			}
+// writeChar feeds a single rune through the ANSI/newline/truncation state
+// machine.
+func (w *Writer) writeChar(c rune) error {
+	switch {
+	case c == '\x1B':
+		// ANSI escape sequence
+		w.ansi = true
+		return w.appendRune(c)
+	case w.ansi:
+		if (c >= 0x41 && c <= 0x5a) || (c >= 0x61 && c <= 0x7a) {
+			// ANSI sequence terminated
+			w.ansi = false
+		}
+		return w.appendRune(c)
+	case c == '\n':
+		w.lineLen += runewidth.RuneWidth(c)
+
+		// end of current line
+		if err := w.pad(); err != nil {
+			return err
+		}
+		w.ansiWriter.ResetAnsi()
+		if _, err := w.ansiWriter.Write([]byte{'\n'}); err != nil {
+			return err
+		}
+
+		w.line.Reset()
+		w.lineLen = 0
+		w.truncated = false
+		w.unitWidths = w.unitWidths[:0]
+		w.unitEnds = w.unitEnds[:0]
+
+		return nil
+	default:
+		return w.writeContent([]byte(string(c)), runewidth.RuneWidth(c))
+	}
+}
+
+// writeContent accounts content cells towards lineLen and, unless Truncate
+// has already spent the cell budget for this line, appends it to the
+// current line.
+func (w *Writer) writeContent(content []byte, cw int) error {
+	if w.Truncate && w.Padding > 0 {
+		if w.truncated {
+			// cell budget spent; drop the rest of the line's content
+			return nil
+		}
+
+		if w.lineLen+cw > int(w.Padding) {
+			w.truncated = true
+			return w.truncateLine()
+		}
+	}
+
+	w.lineLen += cw
+	if _, err := w.line.Write(content); err != nil {
+		return err
+	}
+
+	if w.Truncate && w.Padding > 0 {
+		w.unitWidths = append(w.unitWidths, cw)
+		w.unitEnds = append(w.unitEnds, w.line.Len())
+	}
+
+	return nil
+}
+
+// truncateLine is called once content has been found to overflow Padding.
+// It trims previously written units off the tail of the current line, if
+// needed, to make room for a (possibly capped) Ellipsis within the Padding
+// budget, then appends it.
+func (w *Writer) truncateLine() error {
+	ellipsis, ellipsisWidth := w.cappedEllipsis()
+
+	target := int(w.Padding) - ellipsisWidth
+	for w.lineLen > target {
+		last := len(w.unitWidths) - 1
+		end := 0
+		if last > 0 {
+			end = w.unitEnds[last-1]
 		}
+
+		w.lineLen -= w.unitWidths[last]
+		w.line.Truncate(end)
+		w.unitWidths = w.unitWidths[:last]
+		w.unitEnds = w.unitEnds[:last]
+	}
+
+	if ellipsis == "" {
+		return nil
 	}
 
+	if _, err := w.line.WriteString(ellipsis); err != nil {
+		return err
+	}
+	w.lineLen += ellipsisWidth
+
 	return nil
 }
 
-func (w *Writer) writeRune(r rune) (int, error) {
-	bb := make([]byte, utf8.UTFMax)
-	n := utf8.EncodeRune(bb, r)
-	return w.ansiWriter.Write(bb[:n])
+// cappedEllipsis returns Ellipsis trimmed, rune by rune from the end, down
+// to at most Padding cells, along with its resulting width. This keeps an
+// oversized Ellipsis from ever pushing a line past Padding on its own.
+func (w *Writer) cappedEllipsis() (string, int) {
+	if w.Ellipsis == "" {
+		return "", 0
+	}
+
+	ellipsis := w.Ellipsis
+	width := runewidth.StringWidth(ellipsis)
+	for width > int(w.Padding) {
+		r, size := utf8.DecodeLastRuneInString(ellipsis)
+		ellipsis = ellipsis[:len(ellipsis)-size]
+		width -= runewidth.RuneWidth(r)
+	}
+
+	return ellipsis, width
+}
+
+// pad assembles the buffered line together with its fill, according to
+// w.Align, and forwards the result to the underlying ansi.Writer. The
+// trailing newline (if any) is not part of the buffered line and is
+// written separately by the caller.
+func (w *Writer) pad() error {
+	if w.Padding == 0 || uint(w.lineLen) >= w.Padding {
+		_, err := w.ansiWriter.Write(w.line.Bytes())
+		return err
+	}
+
+	fill := int(w.Padding) - w.lineLen
+
+	switch w.Align {
+	case AlignRight:
+		if err := w.writeFill(fill); err != nil {
+			return err
+		}
+		_, err := w.ansiWriter.Write(w.line.Bytes())
+		return err
+	case AlignCenter:
+		left := fill / 2
+		right := fill - left
+
+		if err := w.writeFill(left); err != nil {
+			return err
+		}
+		if _, err := w.ansiWriter.Write(w.line.Bytes()); err != nil {
+			return err
+		}
+		return w.writeFill(right)
+	default:
+		if _, err := w.ansiWriter.Write(w.line.Bytes()); err != nil {
+			return err
+		}
+		return w.writeFill(fill)
+	}
+}
+
+// writeFill writes n cells of padding to the underlying ansi.Writer, using
+// PadFunc when set.
+func (w *Writer) writeFill(n int) error {
+	if n <= 0 {
+		return nil
+	}
+
+	if w.PadFunc != nil {
+		for i := 0; i < n; i++ {
+			w.PadFunc(w.ansiWriter)
+		}
+		return nil
+	}
+
+	_, err := w.ansiWriter.Write(bytes.Repeat([]byte(" "), n))
+	return err
+}
+
+// appendRune appends a single rune to the current line. It's used for ANSI
+// escape sequence bytes, which are always plain runes regardless of
+// FastWidth.
+func (w *Writer) appendRune(r rune) error {
+	_, err := w.line.WriteRune(r)
+	return err
 }
 
 // Close will finish the padding operation.
@@ -127,29 +358,54 @@ func (w *Writer) Close() (err error) {
 	return w.Flush()
 }
 
-// Bytes returns the padded result as a byte slice.
+// Bytes returns the padded result as a byte slice. In pipe mode (a Writer
+// created with NewWriterPipe) this is a no-op returning nil, since content
+// is forwarded directly rather than cached.
 func (w *Writer) Bytes() []byte {
+	if w.pipe {
+		return nil
+	}
 	return w.cache.Bytes()
 }
 
-// String returns the padded result as a string.
+// String returns the padded result as a string. In pipe mode (a Writer
+// created with NewWriterPipe) this is a no-op returning "", since content
+// is forwarded directly rather than cached.
 func (w *Writer) String() string {
+	if w.pipe {
+		return ""
+	}
 	return w.cache.String()
 }
 
 // Flush will finish the padding operation. Always call it before trying to
-// retrieve the final result.
+// retrieve the final result. In pipe mode it writes the final,
+// unterminated line directly to the forward writer; it never touches the
+// cache buffer, which is never allocated in that mode.
 func (w *Writer) Flush() (err error) {
 	if w.lineLen != 0 {
 		if err = w.pad(); err != nil {
 			return
 		}
 	}
+	if w.lineLen == 0 && w.line.Len() != 0 {
+		// a final line holding only ANSI escape sequences gets no fill
+		if _, err = w.ansiWriter.Write(w.line.Bytes()); err != nil {
+			return
+		}
+	}
+
+	if !w.pipe {
+		w.cache.Reset()
+		_, err = w.buf.WriteTo(&w.cache)
+	}
 
-	w.cache.Reset()
-	_, err = w.buf.WriteTo(&w.cache)
 	w.lineLen = 0
 	w.ansi = false
+	w.truncated = false
+	w.line.Reset()
+	w.unitWidths = w.unitWidths[:0]
+	w.unitEnds = w.unitEnds[:0]
 
 	return
 }
@@ -169,7 +425,11 @@ func acquireWriter(width uint) *Writer {
 	w.Padding = width
 	w.lineLen = 0
 	w.ansi = false
+	w.truncated = false
 	w.buf.Reset()
+	w.line.Reset()
+	w.unitWidths = w.unitWidths[:0]
+	w.unitEnds = w.unitEnds[:0]
 
 	return w
 }
@@ -0,0 +1,162 @@
+package padding
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriterAlign(t *testing.T) {
+	tt := []struct {
+		name  string
+		align Align
+		width uint
+		in    string
+		out   string
+	}{
+		{"left", AlignLeft, 10, "hi\n", "hi        \n"},
+		{"right", AlignRight, 10, "hi\n", "        hi\n"},
+		{"center-even-fill", AlignCenter, 6, "hi\n", "  hi  \n"},
+		{"center-odd-fill-biases-right", AlignCenter, 9, "hi\n", "   hi    \n"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := NewWriter(tc.width, nil)
+			w.Align = tc.align
+
+			if _, err := w.Write([]byte(tc.in)); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := w.String(); got != tc.out {
+				t.Errorf("String() = %q, want %q", got, tc.out)
+			}
+		})
+	}
+}
+
+func TestWriterGraphemeClusters(t *testing.T) {
+	// Family ZWJ sequence: one grapheme cluster of width 2, but 8 cells
+	// when each rune is measured (and padded) independently.
+	family := "\U0001F468‍\U0001F469‍\U0001F467‍\U0001F466"
+
+	t.Run("default measures by grapheme cluster", func(t *testing.T) {
+		w := NewWriter(6, nil)
+
+		if _, err := w.Write([]byte(family + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		want := family + "    \n"
+		if got := w.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("FastWidth falls back to per-rune measurement", func(t *testing.T) {
+		w := NewWriter(6, nil)
+		w.FastWidth = true
+
+		if _, err := w.Write([]byte(family + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		// the cluster's runes measure 2+0+2+0+2+0+2 = 8 cells, which
+		// already overruns Padding(6), so no fill is added.
+		want := family + "\n"
+		if got := w.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestWriterTruncate(t *testing.T) {
+	tt := []struct {
+		name    string
+		ellipsi string
+		in      string
+		out     string
+	}{
+		{
+			name: "fits within width",
+			in:   "ab\n",
+			out:  "ab   \n",
+		},
+		{
+			name: "wide rune overflows budget by less than its own width",
+			// 4 narrow runes (width 1 each) then a CJK rune (width 2):
+			// 4+2 = 6 cells > Padding(5), so the wide rune is dropped, but
+			// the column must still fill out to 5 cells.
+			in:  "abcd世\n",
+			out: "abcd \n",
+		},
+		{
+			name:    "ellipsis exactly fills the budget",
+			ellipsi: ".",
+			in:      "abcdef\n",
+			out:     "abcd.\n",
+		},
+		{
+			name:    "ellipsis wider than padding never truncates content that fits",
+			ellipsi: "......", // width 6, wider than Padding(5)
+			in:      "a\n",
+			out:     "a    \n",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := NewWriter(5, nil)
+			w.Truncate = true
+			w.Ellipsis = tc.ellipsi
+
+			if _, err := w.Write([]byte(tc.in)); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := w.String(); got != tc.out {
+				t.Errorf("String() = %q, want %q", got, tc.out)
+			}
+		})
+	}
+}
+
+func TestNewWriterPipe(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriterPipe(&buf, 5, nil)
+
+	if _, err := w.Write([]byte("ab\ncd")); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, got := "ab   \n", buf.String(); got != want {
+		t.Errorf("completed line wasn't streamed before Close(): buf = %q, want %q", got, want)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, got := "ab   \ncd   ", buf.String(); got != want {
+		t.Errorf("buf after Close() = %q, want %q", got, want)
+	}
+
+	if b := w.Bytes(); b != nil {
+		t.Errorf("Bytes() = %v, want nil in pipe mode", b)
+	}
+	if s := w.String(); s != "" {
+		t.Errorf("String() = %q, want \"\" in pipe mode", s)
+	}
+}